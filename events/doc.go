@@ -0,0 +1,11 @@
+// Package events implements ContainerPilot's EventBus: Subscribers declare
+// a Filter of EventCodes and a Source glob at Register/Subscribe time, and
+// Publish routes each Event only to the Subscribers whose Filter matches
+// it, journaling it and streaming it to any attached HTTP clients along
+// the way.
+//
+// This package only covers the bus itself. Updating jobs, watches,
+// control, and telemetry to declare Filters at Register time, and to
+// populate typed Payloads when they publish, belongs in those packages
+// and has not been done yet -- there is no consumer-side wiring here.
+package events
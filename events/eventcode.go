@@ -0,0 +1,106 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// eventCodeNames maps each EventCode to its external name, used both by
+// ParseEventCode and by String().
+var eventCodeNames = map[EventCode]string{
+	None:             "None",
+	ExitSuccess:      "ExitSuccess",
+	ExitFailed:       "ExitFailed",
+	Stopping:         "Stopping",
+	Stopped:          "Stopped",
+	StatusHealthy:    "StatusHealthy",
+	StatusUnhealthy:  "StatusUnhealthy",
+	StatusChanged:    "StatusChanged",
+	TimerExpired:     "TimerExpired",
+	EnterMaintenance: "EnterMaintenance",
+	ExitMaintenance:  "ExitMaintenance",
+	Error:            "Error",
+	Quit:             "Quit",
+	Startup:          "Startup",
+	Shutdown:         "Shutdown",
+}
+
+// String returns the EventCode's name, e.g. "StatusUnhealthy". This stands
+// in for the `stringer`-generated String() referenced by the go:generate
+// comment in events.go until that's wired into the build; keep eventCodeNames
+// in sync with the EventCode enum.
+func (c EventCode) String() string {
+	if name, ok := eventCodeNames[c]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// ParseEventCode looks up the EventCode with the given name (as produced by
+// String()), for callers that take an EventCode as external input, such as
+// the events/stream server's `?codes=` query parameter.
+func ParseEventCode(name string) (EventCode, bool) {
+	for code, candidate := range eventCodeNames {
+		if candidate == name {
+			return code, true
+		}
+	}
+	return None, false
+}
+
+// MarshalJSON implements json.Marshaler, rendering an EventCode as its
+// snake_case name (e.g. "status_unhealthy") instead of its bare integer
+// value, so external consumers of the journal/stream don't need this
+// package's source to interpret an Event.
+func (c EventCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toSnakeCase(c.String()))
+}
+
+// toSnakeCase lowercases a PascalCase name and inserts underscores at each
+// word boundary, e.g. "StatusUnhealthy" -> "status_unhealthy"
+func toSnakeCase(name string) string {
+	var out strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				out.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the inverse of MarshalJSON:
+// it turns a snake_case name like "status_unhealthy" back into the
+// EventCode StatusUnhealthy. Without this, anything that marshals an
+// Event (the journal, events/stream) can't read its own output back.
+func (c *EventCode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	code, ok := ParseEventCode(fromSnakeCase(name))
+	if !ok {
+		return fmt.Errorf("events: unknown EventCode %q", name)
+	}
+	*c = code
+	return nil
+}
+
+// fromSnakeCase is the inverse of toSnakeCase, e.g.
+// "status_unhealthy" -> "StatusUnhealthy"
+func fromSnakeCase(name string) string {
+	var out strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		out.WriteString(strings.ToUpper(word[:1]))
+		out.WriteString(word[1:])
+	}
+	return out.String()
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestEventCodeJSONRoundTrip guards against the regression fixed in
+// aaab04b, where MarshalJSON had no inverse and every journaled Event
+// silently failed to unmarshal, truncating the journal on every restart.
+func TestEventCodeJSONRoundTrip(t *testing.T) {
+	for code, name := range eventCodeNames {
+		data, err := json.Marshal(code)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", name, err)
+		}
+		want := `"` + toSnakeCase(name) + `"`
+		if string(data) != want {
+			t.Fatalf("marshal %s = %s, want %s", name, data, want)
+		}
+
+		var got EventCode
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s from %s: %v", name, data, err)
+		}
+		if got != code {
+			t.Fatalf("round-trip %s: got %v, want %v", name, got, code)
+		}
+	}
+}
+
+func TestEventCodeUnmarshalUnknown(t *testing.T) {
+	var code EventCode
+	if err := json.Unmarshal([]byte(`"not_a_real_code"`), &code); err == nil {
+		t.Fatal("expected an error unmarshalling an unknown EventCode, got nil")
+	}
+}
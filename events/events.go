@@ -1,15 +1,42 @@
 package events
 
 import (
+	"encoding/json"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
 
 // Event ...
 type Event struct {
-	Code   EventCode
-	Source string
+	Code      EventCode
+	Source    string
+	Timestamp time.Time
+	Payload   interface{}
+}
+
+// eventJSON is Event's wire representation. Keeping it separate from Event
+// means adding unexported bookkeeping to Event later can't silently change
+// the schema the journal and events/stream server hand to external
+// consumers.
+type eventJSON struct {
+	Code      EventCode   `json:"code"`
+	Source    string      `json:"source"`
+	Timestamp time.Time   `json:"timestamp,omitempty"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{
+		Code:      e.Code,
+		Source:    e.Source,
+		Timestamp: e.Timestamp,
+		Payload:   e.Payload,
+	})
 }
 
 // go:generate stringer -type EventCode
@@ -44,37 +71,229 @@ var (
 	NonEvent       = Event{Code: None, Source: ""}
 )
 
+// Subscriber is implemented by anything that wants to Register or Subscribe
+// to receive Events from the EventBus.
+type Subscriber interface {
+	Receive(Event)
+}
+
+// Filter describes the subset of Events a Subscriber wants delivered: a set
+// of EventCodes to match against Event.Code, and a glob pattern to match
+// against Event.Source. An empty Codes matches every EventCode, and a Source
+// of "*" (or "") matches every source. Source globs only support a single
+// trailing "*", e.g. "job:web-*".
+type Filter struct {
+	Codes  []EventCode
+	Source string
+}
+
+// Matches returns true if the Event satisfies the Filter
+func (f Filter) Matches(event Event) bool {
+	if len(f.Codes) > 0 {
+		codeMatch := false
+		for _, code := range f.Codes {
+			if code == event.Code {
+				codeMatch = true
+				break
+			}
+		}
+		if !codeMatch {
+			return false
+		}
+	}
+	return sourceMatches(f.Source, event.Source)
+}
+
+// sourceMatches applies a Filter's Source glob to an Event's Source
+func sourceMatches(pattern, source string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(source, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == source
+}
+
+// defaultQueueCapacity is the buffered channel size for a subscription's
+// event queue when no explicit capacity is given to Subscribe/SubscribeAsync
+const defaultQueueCapacity = 2048
+
+// asyncConcurrency is the number of goroutines draining a non-transactional
+// async subscription's queue concurrently
+const asyncConcurrency = 4
+
+// subscription tracks the Filters and delivery queue registered for a
+// single Subscriber. Publish never calls Receive directly; it hands the
+// Event to queue, and one or more drain goroutines own delivery.
+type subscription struct {
+	filters []Filter
+	queue   chan Event
+	dropped uint64 // atomic; Events dropped because queue was full
+	pending int64  // atomic; Events queued but not yet returned from Receive
+}
+
+// matches returns true if the Event satisfies any of the subscription's
+// Filters
+func (sub *subscription) matches(event Event) bool {
+	for _, filter := range sub.filters {
+		if filter.Matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
 // EventBus ...
 type EventBus struct {
-	registry  map[Subscriber]bool
+	registry  map[Subscriber]*subscription
 	lock      *sync.RWMutex
 	reloading bool
 	reloaded  chan bool
 	done      chan bool
+	journal   *EventLog
 }
 
 // NewEventBus ...
 func NewEventBus() *EventBus {
 	lock := &sync.RWMutex{}
-	reg := make(map[Subscriber]bool)
+	reg := make(map[Subscriber]*subscription)
 	done := make(chan bool, 1)
 	reloaded := make(chan bool, 1)
 	bus := &EventBus{registry: reg, lock: lock, done: done, reloaded: reloaded}
 	return bus
 }
 
-// Register the Subscriber for all Events
+// Register the Subscriber for all Events. This is a convenience wrapper
+// around Subscribe for the common case of wanting every Event.
 func (bus *EventBus) Register(subscriber Subscriber) {
+	bus.Subscribe(subscriber, Filter{Source: "*"})
+}
+
+// AttachEventLog wires an EventLog into the bus so every published Event
+// is journaled and RegisterFrom can replay history to late Subscribers.
+func (bus *EventBus) AttachEventLog(journal *EventLog) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	bus.journal = journal
+}
+
+// RegisterFrom registers subscriber for all Events the same way Register
+// does, but first replays any journaled Events published at or after
+// since (see AttachEventLog) so the Subscriber can recover state across a
+// Reload without missing the transitions it happened to miss while it
+// wasn't yet attached. The replay and the live attach happen atomically
+// under bus.lock, so no Event published in between is missed or delivered
+// twice.
+func (bus *EventBus) RegisterFrom(subscriber Subscriber, since time.Time) {
+	filter := Filter{Source: "*"}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	if bus.journal != nil {
+		for _, entry := range bus.journal.Since(since) {
+			if filter.Matches(entry.Event) {
+				subscriber.Receive(entry.Event)
+			}
+		}
+	}
+	bus.subscribeLocked(subscriber, filter, defaultQueueCapacity, 1)
+}
+
+// Subscribe registers the Subscriber to receive only the Events matching
+// filter, delivered one-at-a-time and in order by a single background
+// goroutine. Subscribe may be called more than once for the same Subscriber
+// to add additional Filters. An optional capacity overrides the default
+// buffered queue size (2048) for this Subscriber.
+func (bus *EventBus) Subscribe(subscriber Subscriber, filter Filter, capacity ...int) {
 	bus.lock.Lock()
 	defer bus.lock.Unlock()
-	bus.registry[subscriber] = true
+	bus.subscribeLocked(subscriber, filter, resolveCapacity(capacity), 1)
+}
+
+// SubscribeAsync registers the Subscriber the same way as Subscribe, but
+// if transactional is false the Subscriber's queue is drained by several
+// goroutines concurrently, trading delivery order for throughput.
+func (bus *EventBus) SubscribeAsync(subscriber Subscriber, filter Filter, transactional bool, capacity ...int) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	workers := asyncConcurrency
+	if transactional {
+		workers = 1
+	}
+	bus.subscribeLocked(subscriber, filter, resolveCapacity(capacity), workers)
+}
+
+// resolveCapacity returns the first capacity given, or defaultQueueCapacity
+// if none was given
+func resolveCapacity(capacity []int) int {
+	if len(capacity) > 0 && capacity[0] > 0 {
+		return capacity[0]
+	}
+	return defaultQueueCapacity
+}
+
+// subscribeLocked adds filter to subscriber's subscription, creating the
+// subscription and its drain goroutines on the first call for that
+// Subscriber. Callers must hold bus.lock.
+func (bus *EventBus) subscribeLocked(subscriber Subscriber, filter Filter, capacity, workers int) *subscription {
+	sub, ok := bus.registry[subscriber]
+	if !ok {
+		sub = &subscription{queue: make(chan Event, capacity)}
+		bus.registry[subscriber] = sub
+		for i := 0; i < workers; i++ {
+			go bus.drain(subscriber, sub)
+		}
+	}
+	sub.filters = append(sub.filters, filter)
+	return sub
+}
+
+// drain delivers queued Events to subscriber's Receive until queue is closed
+func (bus *EventBus) drain(subscriber Subscriber, sub *subscription) {
+	for event := range sub.queue {
+		subscriber.Receive(event)
+		atomic.AddInt64(&sub.pending, -1)
+	}
+}
+
+// Topics returns the Filters currently registered for every Subscriber,
+// making the event graph inspectable
+func (bus *EventBus) Topics() map[Subscriber][]Filter {
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+	topics := make(map[Subscriber][]Filter, len(bus.registry))
+	for subscriber, sub := range bus.registry {
+		topics[subscriber] = append([]Filter(nil), sub.filters...)
+	}
+	return topics
+}
+
+// Journal returns the EventLog attached via AttachEventLog, or nil if none
+// has been attached
+func (bus *EventBus) Journal() *EventLog {
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+	return bus.journal
+}
+
+// Dropped returns the number of Events dropped for subscriber because its
+// queue was full, or 0 if subscriber isn't registered. No telemetry sensor
+// reads this count; it is not exposed as a metric.
+func (bus *EventBus) Dropped(subscriber Subscriber) uint64 {
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+	if sub, ok := bus.registry[subscriber]; ok {
+		return atomic.LoadUint64(&sub.dropped)
+	}
+	return 0
 }
 
 // Unregister the Subscriber from all Events
 func (bus *EventBus) Unregister(subscriber Subscriber) {
 	bus.lock.Lock()
 	defer bus.lock.Unlock()
-	if _, ok := bus.registry[subscriber]; ok {
+	if sub, ok := bus.registry[subscriber]; ok {
+		close(sub.queue)
 		delete(bus.registry, subscriber)
 	}
 	if len(bus.registry) == 0 {
@@ -86,15 +305,53 @@ func (bus *EventBus) Unregister(subscriber Subscriber) {
 	}
 }
 
-// Publish an Event to all Subscribers
+// Publish routes an Event to every Subscriber whose Filter matches it. The
+// hand-off to each Subscriber's queue is non-blocking: a Subscriber whose
+// drain goroutine can't keep up has the Event dropped and counted rather
+// than stalling Publish (and every other Subscriber) until it catches up.
 func (bus *EventBus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
 	log.Debugf("event: %v", event)
 	bus.lock.RLock()
 	defer bus.lock.RUnlock()
-	for subscriber := range bus.registry {
-		// sending to an unsubscribed Subscriber shouldn't be a runtime
-		// error, so this is in intentionally allowed to panic here
-		subscriber.Receive(event)
+	if bus.journal != nil {
+		if err := bus.journal.Append(event); err != nil {
+			log.Debugf("could not journal event %v: %v", event, err)
+		}
+	}
+	for subscriber, sub := range bus.registry {
+		if !sub.matches(event) {
+			continue
+		}
+		atomic.AddInt64(&sub.pending, 1)
+		select {
+		case sub.queue <- event:
+		default:
+			atomic.AddInt64(&sub.pending, -1)
+			atomic.AddUint64(&sub.dropped, 1)
+			// %T, not %v: the concrete Subscriber may mutate its own state
+			// from its drain goroutine while we're formatting this, and a
+			// %v would reflect over live fields and race with it.
+			log.Debugf("subscriber %T blocked, dropping %v", subscriber, event)
+		}
+	}
+}
+
+// Flush blocks until every Subscriber's queue has been drained and each
+// queued Event's Receive call has returned
+func (bus *EventBus) Flush() {
+	bus.lock.RLock()
+	subs := make([]*subscription, 0, len(bus.registry))
+	for _, sub := range bus.registry {
+		subs = append(subs, sub)
+	}
+	bus.lock.RUnlock()
+	for _, sub := range subs {
+		for atomic.LoadInt64(&sub.pending) > 0 {
+			time.Sleep(time.Millisecond)
+		}
 	}
 }
 
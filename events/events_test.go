@@ -0,0 +1,127 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingSubscriber blocks its first Receive call until release is
+// closed, so a test can force its queue to fill up deterministically.
+type blockingSubscriber struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (sub *blockingSubscriber) Receive(event Event) {
+	sub.once.Do(func() { close(sub.started) })
+	<-sub.release
+}
+
+func TestPublishDropsWhenQueueFull(t *testing.T) {
+	bus := NewEventBus()
+	sub := &blockingSubscriber{started: make(chan struct{}), release: make(chan struct{})}
+	bus.Subscribe(sub, Filter{Source: "*"}, 1)
+
+	event := Event{Code: ExitSuccess, Source: "test"}
+	bus.Publish(event) // picked up by the drain goroutine, which then blocks in Receive
+	<-sub.started
+
+	bus.Publish(event) // fills the capacity-1 queue
+	bus.Publish(event) // queue is full: dropped
+
+	dropped := bus.Dropped(sub)
+	close(sub.release)
+	bus.Unregister(sub)
+
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", dropped)
+	}
+}
+
+// recordingSubscriber appends every Event it receives, guarded by a mutex
+// since delivery happens on a background drain goroutine.
+type recordingSubscriber struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (sub *recordingSubscriber) Receive(event Event) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.events = append(sub.events, event)
+}
+
+func (sub *recordingSubscriber) received() []Event {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	out := make([]Event, len(sub.events))
+	copy(out, sub.events)
+	return out
+}
+
+func TestPublishRoutesByFilter(t *testing.T) {
+	bus := NewEventBus()
+
+	codeSub := &recordingSubscriber{}
+	bus.Subscribe(codeSub, Filter{Codes: []EventCode{ExitFailed}})
+
+	sourceSub := &recordingSubscriber{}
+	bus.Subscribe(sourceSub, Filter{Source: "job:web-*"})
+
+	bus.Publish(Event{Code: ExitFailed, Source: "job:db-1"})   // matches codeSub only
+	bus.Publish(Event{Code: ExitSuccess, Source: "job:web-1"}) // matches sourceSub only
+	bus.Publish(Event{Code: ExitFailed, Source: "job:web-2"})  // matches both
+	bus.Flush()
+
+	codeEvents := codeSub.received()
+	if len(codeEvents) != 2 {
+		t.Fatalf("expected codeSub to receive 2 Events, got %d: %+v", len(codeEvents), codeEvents)
+	}
+	for _, event := range codeEvents {
+		if event.Code != ExitFailed {
+			t.Fatalf("codeSub received an Event outside its Filter: %+v", event)
+		}
+	}
+
+	sourceEvents := sourceSub.received()
+	if len(sourceEvents) != 2 {
+		t.Fatalf("expected sourceSub to receive 2 Events, got %d: %+v", len(sourceEvents), sourceEvents)
+	}
+	for _, event := range sourceEvents {
+		if !strings.HasPrefix(event.Source, "job:web-") {
+			t.Fatalf("sourceSub received an Event outside its Filter: %+v", event)
+		}
+	}
+
+	topics := bus.Topics()
+	if len(topics[codeSub]) != 1 || topics[codeSub][0].Codes[0] != ExitFailed {
+		t.Fatalf("Topics() did not report codeSub's Filter: %+v", topics[codeSub])
+	}
+	if len(topics[sourceSub]) != 1 || topics[sourceSub][0].Source != "job:web-*" {
+		t.Fatalf("Topics() did not report sourceSub's Filter: %+v", topics[sourceSub])
+	}
+
+	bus.Unregister(codeSub)
+	bus.Unregister(sourceSub)
+}
+
+func TestSourceMatchesGlob(t *testing.T) {
+	cases := []struct {
+		pattern, source string
+		want            bool
+	}{
+		{"*", "anything", true},
+		{"", "anything", true},
+		{"job:web-*", "job:web-1", true},
+		{"job:web-*", "job:db-1", false},
+		{"job:web-1", "job:web-1", true},
+		{"job:web-1", "job:web-2", false},
+	}
+	for _, c := range cases {
+		if got := sourceMatches(c.pattern, c.source); got != c.want {
+			t.Errorf("sourceMatches(%q, %q) = %v, want %v", c.pattern, c.source, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// JournalEntry is a single published Event as recorded in an EventLog,
+// tagged with a monotonic sequence number and the time it was published.
+type JournalEntry struct {
+	Seq       uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Event     Event     `json:"event"`
+}
+
+// EventLog persists a rolling window of published Events to a
+// newline-delimited JSON file under the ContainerPilot state dir, so
+// RegisterFrom can replay recent history to a Subscriber that attaches
+// after a Reload or restart.
+type EventLog struct {
+	lock                sync.Mutex
+	path                string
+	file                *os.File
+	maxEntries          int
+	maxAge              time.Duration
+	seq                 uint64
+	entries             []JournalEntry
+	appendsSinceCompact int
+}
+
+// compactEvery is how many Appends accumulate before the on-disk journal
+// is recompacted against the retention policy, so a long-running process
+// doesn't grow the file without bound between restarts.
+const compactEvery = 256
+
+// NewEventLog opens (creating if necessary) the journal file "events.journal"
+// under dir. Any entries already on disk are read back into memory and the
+// retention policy is applied immediately, so the file doesn't grow without
+// bound across restarts. A maxEntries or maxAge of 0 disables that half of
+// the policy.
+func NewEventLog(dir string, maxEntries int, maxAge time.Duration) (*EventLog, error) {
+	journal := &EventLog{
+		path:       filepath.Join(dir, "events.journal"),
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+	}
+	if err := journal.load(); err != nil {
+		return nil, err
+	}
+	if err := journal.compact(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(journal.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	journal.file = file
+	return journal, nil
+}
+
+// load reads any existing journal file back into memory
+func (journal *EventLog) load() error {
+	file, err := os.Open(journal.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var corrupt int
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			corrupt++
+			log.Warnf("skipping corrupt journal entry in %s: %v", journal.path, err)
+			continue
+		}
+		journal.entries = append(journal.entries, entry)
+		if entry.Seq > journal.seq {
+			journal.seq = entry.Seq
+		}
+	}
+	if corrupt > 0 {
+		log.Warnf("journal %s: skipped %d corrupt entries out of %d", journal.path, corrupt, corrupt+len(journal.entries))
+	}
+	return scanner.Err()
+}
+
+// compact applies the retention policy to the in-memory entries and
+// rewrites the journal file to match
+func (journal *EventLog) compact() error {
+	journal.entries = journal.retain(journal.entries)
+	tmpPath := journal.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, entry := range journal.entries {
+		if err := enc.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, journal.path)
+}
+
+// retain returns the subset of entries satisfying the retention policy
+func (journal *EventLog) retain(entries []JournalEntry) []JournalEntry {
+	if journal.maxAge > 0 {
+		cutoff := time.Now().Add(-journal.maxAge)
+		pruned := entries[:0:0]
+		for _, entry := range entries {
+			if entry.Timestamp.After(cutoff) {
+				pruned = append(pruned, entry)
+			}
+		}
+		entries = pruned
+	}
+	if journal.maxEntries > 0 && len(entries) > journal.maxEntries {
+		entries = entries[len(entries)-journal.maxEntries:]
+	}
+	return entries
+}
+
+// Append records event in the journal under the next sequence number, then
+// enforces the retention policy in memory. The on-disk file is append-only
+// between Appends, but every compactEvery Appends (and again on the next
+// NewEventLog) it's rewritten to match, so the file doesn't grow without
+// bound over the life of a long-running process.
+func (journal *EventLog) Append(event Event) error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	journal.seq++
+	entry := JournalEntry{Seq: journal.seq, Timestamp: time.Now(), Event: event}
+	if err := json.NewEncoder(journal.file).Encode(entry); err != nil {
+		return err
+	}
+	journal.entries = append(journal.entries, entry)
+	journal.entries = journal.retain(journal.entries)
+	journal.appendsSinceCompact++
+	if journal.appendsSinceCompact >= compactEvery {
+		journal.appendsSinceCompact = 0
+		return journal.recompact()
+	}
+	return nil
+}
+
+// recompact rewrites the on-disk journal to match the retained in-memory
+// entries, then reopens the append handle so subsequent Appends keep
+// writing to the replaced file. Callers must hold journal.lock.
+func (journal *EventLog) recompact() error {
+	if err := journal.file.Close(); err != nil {
+		return err
+	}
+	if err := journal.compact(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(journal.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	journal.file = file
+	return nil
+}
+
+// Since returns the journaled entries published at or after t, oldest first
+func (journal *EventLog) Since(t time.Time) []JournalEntry {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	var entries []JournalEntry
+	for _, entry := range journal.entries {
+		if !entry.Timestamp.Before(t) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SinceSeq returns the journaled entries with a sequence number greater
+// than seq, oldest first, for clients that track their position by
+// sequence number rather than wall-clock time (e.g. the events/stream
+// server's `?since=<seq>` replay).
+func (journal *EventLog) SinceSeq(seq uint64) []JournalEntry {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	var entries []JournalEntry
+	for _, entry := range journal.entries {
+		if entry.Seq > seq {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Close flushes and closes the underlying journal file
+func (journal *EventLog) Close() error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	return journal.file.Close()
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEventLogAppendAndReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journal, err := NewEventLog(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{Code: ExitFailed, Source: "job:web-1"}
+	if err := journal.Append(event); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewEventLog(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	entries := reopened.Since(time.Time{})
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry after reopen, got %d", len(entries))
+	}
+	if entries[0].Event.Code != ExitFailed || entries[0].Event.Source != "job:web-1" {
+		t.Fatalf("unexpected entry after reopen: %+v", entries[0])
+	}
+}
+
+func TestEventLogRetentionByCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journal, err := NewEventLog(dir, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := journal.Append(Event{Code: ExitSuccess, Source: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := journal.Since(time.Time{})
+	if len(entries) != 2 {
+		t.Fatalf("expected retention to keep 2 entries, got %d", len(entries))
+	}
+	if entries[0].Seq != 2 || entries[1].Seq != 3 {
+		t.Fatalf("expected the 2 most recent entries (seq 2, 3), got seqs %d, %d",
+			entries[0].Seq, entries[1].Seq)
+	}
+}
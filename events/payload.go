@@ -0,0 +1,25 @@
+package events
+
+import "time"
+
+// ExitFailedPayload is the Payload carried by an ExitFailed Event, giving a
+// Subscriber the detail it would otherwise have to re-query the Runner for.
+type ExitFailedPayload struct {
+	ExitCode int           `json:"exit_code"`
+	Stderr   string        `json:"stderr"`
+	Duration time.Duration `json:"duration"`
+}
+
+// StatusChangedPayload is the Payload carried by a StatusChanged Event.
+// From and To are the StatusHealthy/StatusUnhealthy EventCodes the check
+// transitioned between.
+type StatusChangedPayload struct {
+	From EventCode `json:"from"`
+	To   EventCode `json:"to"`
+}
+
+// TimerExpiredPayload is the Payload carried by a TimerExpired Event
+type TimerExpiredPayload struct {
+	Name   string        `json:"name"`
+	Period time.Duration `json:"period"`
+}
@@ -0,0 +1,105 @@
+// Package stream exposes an EventBus as an HTTP streaming endpoint, so
+// sidecar tooling (log shippers, external supervisors, compose-style UIs)
+// can observe ContainerPilot state without embedding the binary, the same
+// way Docker/wings expose daemon message/console/status/stats topics to
+// remote consumers over a socket.
+package stream
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miguelramosfdz/containerpilot/events"
+)
+
+// Handler streams Events from an EventBus to HTTP clients as
+// newline-delimited JSON. Mount it on the control socket's mux, e.g.
+// mux.Handle("/v3/events", stream.NewHandler(bus)).
+type Handler struct {
+	bus *events.EventBus
+}
+
+// NewHandler returns a Handler that streams Events from bus
+func NewHandler(bus *events.EventBus) *Handler {
+	return &Handler{bus: bus}
+}
+
+// ServeHTTP implements http.Handler. Query parameters:
+//
+//	codes  comma-separated EventCode names, e.g. "StatusUnhealthy,ExitFailed"
+//	source a Filter source glob, e.g. "job:web-*" (default "*")
+//	since  a journal sequence number to replay from before attaching live
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	filter := parseFilter(r)
+
+	var since uint64
+	hasSince := r.URL.Query().Get("since") != ""
+	if hasSince {
+		var err error
+		since, err = strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	if hasSince {
+		if journal := h.bus.Journal(); journal != nil {
+			for _, entry := range journal.SinceSeq(since) {
+				if filter.Matches(entry.Event) {
+					enc.Encode(entry)
+				}
+			}
+			flusher.Flush()
+		}
+	}
+
+	sub := &clientSubscriber{enc: enc, flusher: flusher}
+	h.bus.SubscribeAsync(sub, filter, true)
+	defer h.bus.Unregister(sub)
+
+	<-r.Context().Done()
+}
+
+// parseFilter builds an events.Filter from the request's query parameters
+func parseFilter(r *http.Request) events.Filter {
+	filter := events.Filter{Source: "*"}
+	if source := r.URL.Query().Get("source"); source != "" {
+		filter.Source = source
+	}
+	if codes := r.URL.Query().Get("codes"); codes != "" {
+		for _, name := range strings.Split(codes, ",") {
+			if code, ok := events.ParseEventCode(name); ok {
+				filter.Codes = append(filter.Codes, code)
+			}
+		}
+	}
+	return filter
+}
+
+// clientSubscriber adapts an HTTP connection into an events.Subscriber,
+// writing each delivered Event as a JSON line and flushing immediately so
+// it reaches the client without buffering delay.
+type clientSubscriber struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+// Receive implements events.Subscriber
+func (sub *clientSubscriber) Receive(event events.Event) {
+	if err := sub.enc.Encode(event); err != nil {
+		return
+	}
+	sub.flusher.Flush()
+}
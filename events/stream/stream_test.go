@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miguelramosfdz/containerpilot/events"
+)
+
+// TestServeHTTPInvalidSinceReturns400BeforeBody guards against the
+// regression fixed in b4177e9, where an invalid ?since= still got a
+// committed 200 because WriteHeader ran before the value was validated.
+func TestServeHTTPInvalidSinceReturns400BeforeBody(t *testing.T) {
+	bus := events.NewEventBus()
+	handler := NewHandler(bus)
+
+	req := httptest.NewRequest("GET", "/v3/events?since=not-a-number", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "invalid since") {
+		t.Fatalf("expected an \"invalid since\" error body, got %q", rec.Body.String())
+	}
+}
+
+// TestServeHTTPStreamsMatchingEventAndCleansUp covers the live-attach path:
+// a connection scoped to ?codes=ExitFailed receives a matching Event, and
+// Unregister runs once the client disconnects.
+func TestServeHTTPStreamsMatchingEventAndCleansUp(t *testing.T) {
+	bus := events.NewEventBus()
+	handler := NewHandler(bus)
+
+	req := httptest.NewRequest("GET", "/v3/events?codes=ExitFailed", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	waitForSubscriberCount(t, bus, 1)
+
+	bus.Publish(events.Event{Code: events.ExitFailed, Source: "job:web-1"})
+	bus.Flush()
+
+	cancel()
+	<-done
+
+	if !strings.Contains(rec.Body.String(), "exit_failed") {
+		t.Fatalf("expected the streamed body to contain the published event, got %q", rec.Body.String())
+	}
+	if got := len(bus.Topics()); got != 0 {
+		t.Fatalf("expected Unregister on disconnect to leave no Subscribers, got %d", got)
+	}
+}
+
+func waitForSubscriberCount(t *testing.T, bus *events.EventBus, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if len(bus.Topics()) == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d subscriber(s), got %d", want, len(bus.Topics()))
+}